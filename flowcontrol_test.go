@@ -0,0 +1,89 @@
+package serial
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// lineControlTransport is a noopTransport that also implements
+// lineController, so SerialPort's line-control methods delegate to it
+// instead of returning ErrFlowControlUnsupported.
+type lineControlTransport struct {
+	noopTransport
+	rts, dtr bool
+	cts, dsr bool
+	brk      time.Duration
+}
+
+func (t *lineControlTransport) SetRTS(state bool) error { t.rts = state; return nil }
+func (t *lineControlTransport) SetDTR(state bool) error { t.dtr = state; return nil }
+func (t *lineControlTransport) GetCTS() (bool, error)   { return t.cts, nil }
+func (t *lineControlTransport) GetDSR() (bool, error)   { return t.dsr, nil }
+func (t *lineControlTransport) GetDCD() (bool, error)   { return false, nil }
+func (t *lineControlTransport) GetRI() (bool, error)    { return false, nil }
+func (t *lineControlTransport) SendBreak(d time.Duration) error {
+	t.brk = d
+	return nil
+}
+
+func TestLineControlDelegatesToTransport(t *testing.T) {
+	xport := &lineControlTransport{cts: true, dsr: true}
+	sp := &SerialPort{port: xport, portIsOpen: true}
+
+	if err := sp.SetRTS(true); err != nil {
+		t.Fatalf("SetRTS: %v", err)
+	}
+	if !xport.rts {
+		t.Fatal("SetRTS(true) did not reach the transport")
+	}
+	if err := sp.SetDTR(true); err != nil {
+		t.Fatalf("SetDTR: %v", err)
+	}
+	if !xport.dtr {
+		t.Fatal("SetDTR(true) did not reach the transport")
+	}
+
+	cts, err := sp.GetCTS()
+	if err != nil || !cts {
+		t.Fatalf("GetCTS = %v, %v, want true, nil", cts, err)
+	}
+	dsr, err := sp.GetDSR()
+	if err != nil || !dsr {
+		t.Fatalf("GetDSR = %v, %v, want true, nil", dsr, err)
+	}
+
+	if err := sp.SendBreak(50 * time.Millisecond); err != nil {
+		t.Fatalf("SendBreak: %v", err)
+	}
+	if xport.brk != 50*time.Millisecond {
+		t.Fatalf("SendBreak duration = %v, want 50ms", xport.brk)
+	}
+}
+
+// TestLineControlUnsupportedWithoutTransportSupport covers a transport that
+// doesn't implement lineController (e.g. TCPTransport): every line-control
+// method should report ErrFlowControlUnsupported instead of panicking on
+// a failed type assertion.
+func TestLineControlUnsupportedWithoutTransportSupport(t *testing.T) {
+	sp := &SerialPort{port: noopTransport{}, portIsOpen: true}
+
+	if err := sp.SetRTS(true); !errors.Is(err, ErrFlowControlUnsupported) {
+		t.Fatalf("SetRTS error = %v, want ErrFlowControlUnsupported", err)
+	}
+	if _, err := sp.GetCTS(); !errors.Is(err, ErrFlowControlUnsupported) {
+		t.Fatalf("GetCTS error = %v, want ErrFlowControlUnsupported", err)
+	}
+	if err := sp.SendBreak(time.Millisecond); !errors.Is(err, ErrFlowControlUnsupported) {
+		t.Fatalf("SendBreak error = %v, want ErrFlowControlUnsupported", err)
+	}
+}
+
+func TestSetFlowControl(t *testing.T) {
+	sp := &SerialPort{}
+	sp.SetFlowControl(true, true, true)
+	if !sp.rtsFlowControl || !sp.dtrFlowControl || !sp.xonFlowControl {
+		t.Fatalf("SetFlowControl(true, true, true) = %v, %v, %v, want all true",
+			sp.rtsFlowControl, sp.dtrFlowControl, sp.xonFlowControl)
+	}
+}
@@ -0,0 +1,30 @@
+package modbus
+
+// crc16 computes the standard Modbus CRC-16 (poly 0xA001, init 0xFFFF,
+// reflected) over data and returns it as a uint16; RTU framing puts its
+// low byte on the wire first.
+func crc16(data []byte) uint16 {
+	var crc uint16 = 0xFFFF
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&0x0001 != 0 {
+				crc >>= 1
+				crc ^= 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// lrc computes the LRC used by Modbus ASCII framing: the two's complement
+// of the 8-bit sum of the binary message bytes.
+func lrc(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return byte(-int8(sum))
+}
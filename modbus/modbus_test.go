@@ -0,0 +1,201 @@
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DennisMao/serial"
+)
+
+func TestCRC16KnownVector(t *testing.T) {
+	// Read Holding Registers request for slave 1, addr 0, qty 10.
+	req := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A}
+	got := crc16(req)
+	want := uint16(0xCDC5)
+	if got != want {
+		t.Fatalf("crc16(%x) = %#04x, want %#04x", req, got, want)
+	}
+}
+
+func TestLRC(t *testing.T) {
+	msg := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A}
+	sum := lrc(msg)
+	var total byte
+	for _, b := range msg {
+		total += b
+	}
+	total += sum
+	if total != 0 {
+		t.Fatalf("lrc(%x) = %#02x, checksum did not cancel: total %#02x", msg, sum, total)
+	}
+}
+
+func TestFrameRTU(t *testing.T) {
+	c := &Client{mode: RTU}
+	frame := c.frameRTU([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A})
+	if len(frame) != 8 {
+		t.Fatalf("frameRTU length = %d, want 8", len(frame))
+	}
+	crc := crc16(frame[:6])
+	if frame[6] != byte(crc) || frame[7] != byte(crc>>8) {
+		t.Fatalf("frameRTU CRC bytes = %x, want lo=%#02x hi=%#02x", frame[6:8], byte(crc), byte(crc>>8))
+	}
+}
+
+func TestFrameASCII(t *testing.T) {
+	c := &Client{mode: ASCII}
+	frame := c.frameASCII([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A})
+	if frame[0] != ':' {
+		t.Fatalf("frameASCII does not start with ':': %q", frame)
+	}
+	if !bytes.HasSuffix(frame, []byte("\r\n")) {
+		t.Fatalf("frameASCII does not end with CRLF: %q", frame)
+	}
+}
+
+func TestDecodeRegisters(t *testing.T) {
+	// byte count 4, two registers: 0x0001 and 0x0002
+	data := []byte{0x04, 0x00, 0x01, 0x00, 0x02}
+	regs, err := decodeRegisters(data)
+	if err != nil {
+		t.Fatalf("decodeRegisters: %v", err)
+	}
+	if len(regs) != 2 || regs[0] != 1 || regs[1] != 2 {
+		t.Fatalf("decodeRegisters = %v, want [1 2]", regs)
+	}
+}
+
+func TestDecodeBits(t *testing.T) {
+	// byte count 1, bits 0b00000101 -> coil0=true, coil1=false, coil2=true
+	data := []byte{0x01, 0x05}
+	bits := decodeBits(data, 3)
+	want := []bool{true, false, true}
+	for i, b := range want {
+		if bits[i] != b {
+			t.Fatalf("decodeBits[%d] = %v, want %v", i, bits[i], b)
+		}
+	}
+}
+
+// newLoopbackClient wires a Client to a serial.SerialPort backed by
+// serial.TCPTransport pointed at a local TCP listener, so Client.request
+// can be driven end-to-end without real hardware. accept is called once
+// per connection the listener accepts: one for the port's initial Open,
+// and one more for each idle-timeout reopen, which dials a fresh connection.
+func newLoopbackClient(t *testing.T, mode Mode, accept func(conn net.Conn)) (*Client, *int32) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var accepted int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			go accept(conn)
+		}
+	}()
+
+	// serial.New logs to ./log/..., which only succeeds if the directory
+	// already exists.
+	if err := os.MkdirAll("log", 0755); err != nil {
+		t.Fatalf("mkdir log: %v", err)
+	}
+	port := serial.New()
+	port.Verbose = false
+	port.SetTransport(serial.NewTCPTransport(ln.Addr().String()))
+	if err := port.Open("loopback", 19200, "8", "1s", "N", "1"); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { port.Close() })
+
+	c := NewClient(port, "loopback", 19200, "8", "N", "1", mode)
+	c.Timeout = time.Second
+	return c, &accepted
+}
+
+// rtuHoldingRegistersSlave reads the fixed-length 8-byte Read Holding
+// Registers request frame and replies with regs, framed as RTU.
+func rtuHoldingRegistersSlave(regs []uint16) func(net.Conn) {
+	return func(conn net.Conn) {
+		defer conn.Close()
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		resp := []byte{req[0], req[1], byte(len(regs) * 2)}
+		for _, r := range regs {
+			resp = append(resp, byte(r>>8), byte(r))
+		}
+		crc := crc16(resp)
+		resp = append(resp, byte(crc), byte(crc>>8))
+		conn.Write(resp)
+	}
+}
+
+func TestClientReadHoldingRegistersRTULoopback(t *testing.T) {
+	c, _ := newLoopbackClient(t, RTU, rtuHoldingRegistersSlave([]uint16{0x1234, 0x5678}))
+
+	regs, err := c.ReadHoldingRegisters(1, 0, 2)
+	if err != nil {
+		t.Fatalf("ReadHoldingRegisters: %v", err)
+	}
+	want := []uint16{0x1234, 0x5678}
+	if len(regs) != len(want) || regs[0] != want[0] || regs[1] != want[1] {
+		t.Fatalf("ReadHoldingRegisters = %#v, want %#v", regs, want)
+	}
+}
+
+// shortExceptionSlave replies with an exception response missing its
+// exception-code byte, as a noisy line might deliver.
+func shortExceptionSlave(conn net.Conn) {
+	defer conn.Close()
+	req := make([]byte, 8)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	resp := []byte{req[0], req[1] | exceptionFlag}
+	crc := crc16(resp)
+	resp = append(resp, byte(crc), byte(crc>>8))
+	conn.Write(resp)
+}
+
+func TestClientShortExceptionResponseRTULoopback(t *testing.T) {
+	c, _ := newLoopbackClient(t, RTU, shortExceptionSlave)
+
+	if _, err := c.ReadHoldingRegisters(1, 0, 2); err == nil {
+		t.Fatal("ReadHoldingRegisters: want error for short exception response, got nil")
+	}
+}
+
+func TestClientIdleTimeoutReopenLoopback(t *testing.T) {
+	c, accepted := newLoopbackClient(t, RTU, rtuHoldingRegistersSlave([]uint16{0x0001}))
+	c.IdleTimeout = 10 * time.Millisecond
+
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Fatalf("first ReadHoldingRegisters: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// The idle timeout has now elapsed: request should transparently
+	// reopen the port and still complete this call, rather than handing
+	// ErrIdleTimeoutExpired back to the caller.
+	if _, err := c.ReadHoldingRegisters(1, 0, 1); err != nil {
+		t.Fatalf("second ReadHoldingRegisters after idle reopen: %v", err)
+	}
+	if got := atomic.LoadInt32(accepted); got != 2 {
+		t.Fatalf("accepted connections = %d, want 2 (one per request, reopened in between)", got)
+	}
+}
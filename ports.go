@@ -0,0 +1,44 @@
+package serial
+
+import "context"
+
+// PortInfo describes a serial device discovered on the host, independent
+// of the name the OS happens to have assigned it (which can change across
+// reboots for USB-serial adapters).
+type PortInfo struct {
+	Name         string // e.g. "COM3" or "/dev/ttyUSB0"
+	Description  string
+	VID          string // USB vendor ID, hex, e.g. "2341"
+	PID          string // USB product ID, hex, e.g. "0043"
+	SerialNumber string
+	Manufacturer string
+}
+
+// EventType identifies what happened to a port in a PortEvent.
+type EventType int
+
+const (
+	// PortAttached is sent when a new serial device becomes available.
+	PortAttached EventType = iota
+	// PortDetached is sent when a serial device goes away.
+	PortDetached
+)
+
+// PortEvent is delivered on the channel returned by WatchPorts whenever a
+// serial device is attached or detached.
+type PortEvent struct {
+	Type EventType
+	Port PortInfo
+}
+
+// ListPorts returns the serial devices currently available on this host.
+func ListPorts() ([]PortInfo, error) {
+	return listPorts()
+}
+
+// WatchPorts notifies the returned channel whenever a serial device is
+// attached or detached, until ctx is canceled. The channel is closed after
+// ctx is done.
+func WatchPorts(ctx context.Context) (<-chan PortEvent, error) {
+	return watchPorts(ctx)
+}
@@ -0,0 +1,186 @@
+//go:build darwin
+// +build darwin
+
+package serial
+
+/*
+#cgo LDFLAGS: -framework IOKit -framework CoreFoundation
+#include <IOKit/IOKitLib.h>
+#include <IOKit/serial/IOSerialKeys.h>
+#include <IOKit/usb/USBSpec.h>
+#include <CoreFoundation/CoreFoundation.h>
+#include <stdlib.h>
+
+static CFMutableDictionaryRef matchSerialPorts() {
+	return IOServiceMatching(kIOSerialBSDServiceValue);
+}
+
+static char *cfstringToCString(CFStringRef s) {
+	if (s == NULL) {
+		return NULL;
+	}
+	CFIndex len = CFStringGetLength(s);
+	CFIndex size = CFStringGetMaximumSizeForEncoding(len, kCFStringEncodingUTF8) + 1;
+	char *buf = malloc(size);
+	if (!CFStringGetCString(s, buf, size, kCFStringEncodingUTF8)) {
+		free(buf);
+		return NULL;
+	}
+	return buf;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// listPorts walks the IOKit registry for kIOSerialBSDServiceValue entries
+// and reads the callout device path plus, when the parent is a USB device,
+// its vendor/product identification.
+func listPorts() ([]PortInfo, error) {
+	matching := C.matchSerialPorts()
+	if matching == nil {
+		return nil, fmt.Errorf("serial: IOServiceMatching failed")
+	}
+
+	var iter C.io_iterator_t
+	kr := C.IOServiceGetMatchingServices(C.kIOMasterPortDefault, C.CFDictionaryRef(matching), &iter)
+	if kr != C.KERN_SUCCESS {
+		return nil, fmt.Errorf("serial: IOServiceGetMatchingServices failed: %d", int(kr))
+	}
+	defer C.IOObjectRelease(C.io_object_t(iter))
+
+	var ports []PortInfo
+	for {
+		service := C.IOIteratorNext(iter)
+		if service == 0 {
+			break
+		}
+		ports = append(ports, portInfoFromService(service))
+		C.IOObjectRelease(service)
+	}
+	return ports, nil
+}
+
+func portInfoFromService(service C.io_object_t) PortInfo {
+	var info PortInfo
+
+	calloutKey := C.CFStringCreateWithCString(C.kCFAllocatorDefault, C.kIOCalloutDeviceKey, C.kCFStringEncodingUTF8)
+	defer C.CFRelease(C.CFTypeRef(calloutKey))
+	if callout := C.IORegistryEntryCreateCFProperty(service, calloutKey, C.kCFAllocatorDefault, 0); callout != 0 {
+		defer C.CFRelease(callout)
+		if s := C.cfstringToCString(C.CFStringRef(callout)); s != nil {
+			info.Name = C.GoString(s)
+			C.free(unsafe.Pointer(s))
+		}
+	}
+
+	var usbDevice C.io_registry_entry_t = service
+	for depth := 0; depth < 6; depth++ {
+		var parent C.io_registry_entry_t
+		if C.IORegistryEntryGetParentEntry(usbDevice, C.kIOServicePlane, &parent) != C.KERN_SUCCESS {
+			break
+		}
+		usbDevice = parent
+		if vid := cfNumberProperty(usbDevice, "idVendor"); vid != "" {
+			info.VID = vid
+			info.PID = cfNumberProperty(usbDevice, "idProduct")
+			info.SerialNumber = cfStringProperty(usbDevice, "USB Serial Number")
+			info.Manufacturer = cfStringProperty(usbDevice, "USB Vendor Name")
+			info.Description = cfStringProperty(usbDevice, "USB Product Name")
+			break
+		}
+	}
+	return info
+}
+
+func cfStringProperty(entry C.io_registry_entry_t, key string) string {
+	ckey := C.CString(key)
+	defer C.free(unsafe.Pointer(ckey))
+	cfkey := C.CFStringCreateWithCString(C.kCFAllocatorDefault, ckey, C.kCFStringEncodingUTF8)
+	defer C.CFRelease(C.CFTypeRef(cfkey))
+	val := C.IORegistryEntryCreateCFProperty(entry, cfkey, C.kCFAllocatorDefault, 0)
+	if val == 0 {
+		return ""
+	}
+	defer C.CFRelease(val)
+	s := C.cfstringToCString(C.CFStringRef(val))
+	if s == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(s))
+	return C.GoString(s)
+}
+
+func cfNumberProperty(entry C.io_registry_entry_t, key string) string {
+	ckey := C.CString(key)
+	defer C.free(unsafe.Pointer(ckey))
+	cfkey := C.CFStringCreateWithCString(C.kCFAllocatorDefault, ckey, C.kCFStringEncodingUTF8)
+	defer C.CFRelease(C.CFTypeRef(cfkey))
+	val := C.IORegistryEntryCreateCFProperty(entry, cfkey, C.kCFAllocatorDefault, 0)
+	if val == 0 {
+		return ""
+	}
+	defer C.CFRelease(val)
+	var n C.int32_t
+	if C.CFNumberGetValue(C.CFNumberRef(val), C.kCFNumberSInt32Type, unsafe.Pointer(&n)) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04x", int32(n))
+}
+
+// watchPorts has no cheap blocking primitive without running a CFRunLoop
+// from Go, so it polls ListPorts and diffs the result. This is coarser
+// than IOKit's IOServiceAddMatchingNotification but needs no CGo callback
+// plumbing into the runtime's own run loop.
+func watchPorts(ctx context.Context) (<-chan PortEvent, error) {
+	events := make(chan PortEvent)
+	go func() {
+		defer close(events)
+		seen := map[string]PortInfo{}
+		if ports, err := listPorts(); err == nil {
+			for _, p := range ports {
+				seen[p.Name] = p
+			}
+		}
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := map[string]PortInfo{}
+				ports, err := listPorts()
+				if err != nil {
+					continue
+				}
+				for _, p := range ports {
+					current[p.Name] = p
+					if _, ok := seen[p.Name]; !ok {
+						select {
+						case events <- PortEvent{Type: PortAttached, Port: p}:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+				for name, p := range seen {
+					if _, ok := current[name]; !ok {
+						select {
+						case events <- PortEvent{Type: PortDetached, Port: p}:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+				seen = current
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,164 @@
+//go:build linux
+// +build linux
+
+package serial
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// listPorts enumerates tty devices under /sys/class/tty, skipping the
+// virtual consoles/ptys that have no backing "device" (i.e. no driver),
+// keeps only ttyUSB*, ttyACM* and ttyS* names among the rest, and
+// resolves USB descriptor fields from sysfs for those.
+func listPorts() ([]PortInfo, error) {
+	entries, err := os.ReadDir("/sys/class/tty")
+	if err != nil {
+		return nil, err
+	}
+
+	var ports []PortInfo
+	for _, e := range entries {
+		devLink := filepath.Join("/sys/class/tty", e.Name(), "device")
+		if _, err := os.Lstat(devLink); err != nil {
+			continue
+		}
+		if !strings.HasPrefix(e.Name(), "ttyUSB") && !strings.HasPrefix(e.Name(), "ttyACM") && !strings.HasPrefix(e.Name(), "ttyS") {
+			continue
+		}
+		info := PortInfo{Name: "/dev/" + e.Name()}
+		fillUSBInfo(&info, devLink)
+		ports = append(ports, info)
+	}
+	return ports, nil
+}
+
+// fillUSBInfo walks up from the tty's sysfs device link to the owning USB
+// device directory (which carries idVendor/idProduct/serial/manufacturer)
+// and fills in whatever it finds. Non-USB ports (e.g. ttyS0) simply keep
+// their zero-value fields.
+func fillUSBInfo(info *PortInfo, devLink string) {
+	dir, err := filepath.EvalSymlinks(devLink)
+	if err != nil {
+		return
+	}
+	for i := 0; i < 6 && dir != "/" && dir != "."; i++ {
+		info.VID = readSysfsAttr(filepath.Join(dir, "idVendor"))
+		info.PID = readSysfsAttr(filepath.Join(dir, "idProduct"))
+		info.SerialNumber = readSysfsAttr(filepath.Join(dir, "serial"))
+		info.Manufacturer = readSysfsAttr(filepath.Join(dir, "manufacturer"))
+		info.Description = readSysfsAttr(filepath.Join(dir, "product"))
+		if info.VID != "" {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func readSysfsAttr(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// watchPorts subscribes to the kernel's NETLINK_KOBJECT_UEVENT multicast
+// group and translates "add"/"remove" events for the tty subsystem into
+// PortEvents, re-reading the device's sysfs attributes on each add.
+func watchPorts(ctx context.Context) (<-chan PortEvent, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, fmt.Errorf("serial: opening uevent netlink socket: %w", err)
+	}
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: binding uevent netlink socket: %w", err)
+	}
+	tv := syscall.Timeval{Sec: 1}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: setting uevent netlink receive timeout: %w", err)
+	}
+
+	events := make(chan PortEvent)
+	done := make(chan struct{})
+
+	// fd has a single owner: whichever of ctx cancellation or the read
+	// loop exiting happens first closes it (guarded by closeOnce so the
+	// other path can't also close it, and can't close a fd the runtime
+	// has since reused). On Linux the close does not wake a Recvfrom
+	// already blocked on fd, so fd has a one-second receive timeout after
+	// which the read loop checks ctx itself; neither goroutine leaks.
+	var closeOnce sync.Once
+	closeFD := func() { closeOnce.Do(func() { syscall.Close(fd) }) }
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		closeFD()
+	}()
+
+	go func() {
+		defer close(events)
+		defer close(done)
+
+		buf := make([]byte, 4096)
+		for {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				if ctx.Err() != nil {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				return
+			}
+			ev, ok := parseUevent(buf[:n])
+			if !ok {
+				continue
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// parseUevent decodes a single NUL-separated kobject uevent message of the
+// form "add@/devices/.../ttyUSB0\0ACTION=add\0SUBSYSTEM=tty\0DEVNAME=ttyUSB0\0..."
+// and reports whether it is an "add" or "remove" of a tty device.
+func parseUevent(raw []byte) (PortEvent, bool) {
+	lines := strings.Split(string(raw), "\x00")
+	fields := map[string]string{}
+	for _, l := range lines {
+		if eq := strings.IndexByte(l, '='); eq > 0 {
+			fields[l[:eq]] = l[eq+1:]
+		}
+	}
+	if fields["SUBSYSTEM"] != "tty" || fields["DEVNAME"] == "" {
+		return PortEvent{}, false
+	}
+	info := PortInfo{Name: "/dev/" + fields["DEVNAME"]}
+	switch fields["ACTION"] {
+	case "add":
+		fillUSBInfo(&info, filepath.Join("/sys/class/tty", fields["DEVNAME"], "device"))
+		return PortEvent{Type: PortAttached, Port: info}, true
+	case "remove":
+		return PortEvent{Type: PortDetached, Port: info}, true
+	default:
+		return PortEvent{}, false
+	}
+}
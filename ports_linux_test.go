@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package serial
+
+import "testing"
+
+func TestParseUeventAdd(t *testing.T) {
+	raw := "add@/devices/pci0000:00/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0\x00ACTION=add\x00SUBSYSTEM=tty\x00DEVNAME=ttyUSB0\x00"
+	ev, ok := parseUevent([]byte(raw))
+	if !ok {
+		t.Fatal("parseUevent: want ok=true for a tty add event")
+	}
+	if ev.Type != PortAttached {
+		t.Fatalf("ev.Type = %v, want PortAttached", ev.Type)
+	}
+	if ev.Port.Name != "/dev/ttyUSB0" {
+		t.Fatalf("ev.Port.Name = %q, want %q", ev.Port.Name, "/dev/ttyUSB0")
+	}
+}
+
+func TestParseUeventRemove(t *testing.T) {
+	raw := "remove@/devices/pci0000:00/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0\x00ACTION=remove\x00SUBSYSTEM=tty\x00DEVNAME=ttyUSB0\x00"
+	ev, ok := parseUevent([]byte(raw))
+	if !ok {
+		t.Fatal("parseUevent: want ok=true for a tty remove event")
+	}
+	if ev.Type != PortDetached {
+		t.Fatalf("ev.Type = %v, want PortDetached", ev.Type)
+	}
+}
+
+func TestParseUeventIgnoresOtherSubsystems(t *testing.T) {
+	raw := "add@/devices/pci0000:00/usb1/1-1\x00ACTION=add\x00SUBSYSTEM=usb\x00DEVNAME=bus/usb/001/002\x00"
+	if _, ok := parseUevent([]byte(raw)); ok {
+		t.Fatal("parseUevent: want ok=false for a non-tty subsystem")
+	}
+}
+
+func TestParseUeventIgnoresUnknownAction(t *testing.T) {
+	raw := "change@/devices/.../ttyUSB0\x00ACTION=change\x00SUBSYSTEM=tty\x00DEVNAME=ttyUSB0\x00"
+	if _, ok := parseUevent([]byte(raw)); ok {
+		t.Fatal("parseUevent: want ok=false for an action other than add/remove")
+	}
+}
@@ -0,0 +1,228 @@
+//go:build windows
+// +build windows
+
+package serial
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	modsetupapi = syscall.NewLazyDLL("setupapi.dll")
+	modadvapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procSetupDiGetClassDevsW         = modsetupapi.NewProc("SetupDiGetClassDevsW")
+	procSetupDiEnumDeviceInfo        = modsetupapi.NewProc("SetupDiEnumDeviceInfo")
+	procSetupDiGetDeviceRegistryProp = modsetupapi.NewProc("SetupDiGetDeviceRegistryPropertyW")
+	procSetupDiGetDeviceInstanceIdW  = modsetupapi.NewProc("SetupDiGetDeviceInstanceIdW")
+	procSetupDiOpenDevRegKey         = modsetupapi.NewProc("SetupDiOpenDevRegKey")
+	procSetupDiDestroyDeviceInfoList = modsetupapi.NewProc("SetupDiDestroyDeviceInfoList")
+	procRegQueryValueExW             = modadvapi32.NewProc("RegQueryValueExW")
+	procRegCloseKey                  = modadvapi32.NewProc("RegCloseKey")
+)
+
+const (
+	digcfPresent         = 0x00000002
+	digcfDeviceinterface = 0x00000010
+	spdrpDeviceDesc      = 0x00000000
+	spdrpMfg             = 0x0000000B
+	diregDev             = 0x00000001
+	keyQueryValue        = 0x0001
+	invalidHandleValue   = ^uintptr(0)
+)
+
+type spDevinfoData struct {
+	cbSize    uint32
+	classGUID [16]byte
+	devInst   uint32
+	reserved  uintptr
+}
+
+// guidPorts is the device setup class GUID for COM/LPT ports
+// ({4D36E978-E325-11CE-BFC1-08002BE10318}).
+var guidPorts = syscall.GUID{
+	Data1: 0x4D36E978,
+	Data2: 0xE325,
+	Data3: 0x11CE,
+	Data4: [8]byte{0xBF, 0xC1, 0x08, 0x00, 0x2B, 0xE1, 0x03, 0x18},
+}
+
+// listPorts enumerates the "Ports (COM & LPT)" setup class via
+// SetupDiGetClassDevs/SetupDiEnumDeviceInfo, reads the device description
+// and manufacturer from the device's registry properties, the COM-port name
+// from its device parameters key, and pulls VID/PID/serial out of the
+// hardware instance ID for USB devices.
+func listPorts() ([]PortInfo, error) {
+	h, _, _ := procSetupDiGetClassDevsW.Call(
+		uintptr(unsafe.Pointer(&guidPorts)),
+		0, 0,
+		uintptr(digcfPresent),
+	)
+	if h == invalidHandleValue {
+		return nil, fmt.Errorf("serial: SetupDiGetClassDevs failed")
+	}
+	defer procSetupDiDestroyDeviceInfoList.Call(h)
+
+	var ports []PortInfo
+	for i := uint32(0); ; i++ {
+		var data spDevinfoData
+		data.cbSize = uint32(unsafe.Sizeof(data))
+		ok, _, _ := procSetupDiEnumDeviceInfo.Call(h, uintptr(i), uintptr(unsafe.Pointer(&data)))
+		if ok == 0 {
+			break
+		}
+
+		info := PortInfo{
+			Name:         comNameFor(h, &data),
+			Description:  regStringProperty(h, &data, spdrpDeviceDesc),
+			Manufacturer: regStringProperty(h, &data, spdrpMfg),
+		}
+		instanceID := deviceInstanceID(h, &data)
+		info.VID, info.PID, info.SerialNumber = parseHardwareID(instanceID)
+		if info.Name != "" {
+			ports = append(ports, info)
+		}
+	}
+	return ports, nil
+}
+
+// comNameFor opens the device's hardware-specific registry key and reads
+// "PortName", which is where the SerialComm driver publishes the COMn
+// the OS currently assigned.
+func comNameFor(h uintptr, data *spDevinfoData) string {
+	key, _, _ := procSetupDiOpenDevRegKey.Call(
+		h, uintptr(unsafe.Pointer(data)),
+		uintptr(0x00000001), // DICS_FLAG_GLOBAL
+		0,
+		uintptr(diregDev),
+		uintptr(keyQueryValue),
+	)
+	if key == 0 || key == invalidHandleValue {
+		return ""
+	}
+	defer procRegCloseKey.Call(key)
+
+	name, _ := syscall.UTF16PtrFromString("PortName")
+	buf := make([]uint16, 64)
+	bufLen := uint32(len(buf) * 2)
+	ret, _, _ := procRegQueryValueExW.Call(
+		key,
+		uintptr(unsafe.Pointer(name)),
+		0, 0,
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(unsafe.Pointer(&bufLen)),
+	)
+	if ret != 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+func regStringProperty(h uintptr, data *spDevinfoData, property uint32) string {
+	buf := make([]uint16, 256)
+	ok, _, _ := procSetupDiGetDeviceRegistryProp.Call(
+		h, uintptr(unsafe.Pointer(data)),
+		uintptr(property),
+		0,
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)*2),
+		0,
+	)
+	if ok == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+func deviceInstanceID(h uintptr, data *spDevinfoData) string {
+	buf := make([]uint16, 256)
+	var needed uint32
+	procSetupDiGetDeviceInstanceIdW.Call(
+		h, uintptr(unsafe.Pointer(data)),
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)),
+		uintptr(unsafe.Pointer(&needed)),
+	)
+	return syscall.UTF16ToString(buf)
+}
+
+// parseHardwareID extracts VID/PID/serial from a USB hardware instance ID
+// of the form "USB\VID_2341&PID_0043\5573731383735141E1C1".
+func parseHardwareID(id string) (vid, pid, serial string) {
+	if !strings.HasPrefix(id, "USB\\") {
+		return "", "", ""
+	}
+	parts := strings.Split(id, "\\")
+	if len(parts) >= 3 {
+		serial = parts[2]
+	}
+	if len(parts) >= 2 {
+		for _, field := range strings.Split(parts[1], "&") {
+			if v, ok := strings.CutPrefix(field, "VID_"); ok {
+				vid = strings.ToLower(v)
+			}
+			if p, ok := strings.CutPrefix(field, "PID_"); ok {
+				pid = strings.ToLower(p)
+			}
+		}
+	}
+	return vid, pid, serial
+}
+
+// watchPorts polls ListPorts on a short interval and diffs the result.
+// Driving CM_Register_Notification properly requires a message-only
+// window pumping WM_DEVICECHANGE on the calling thread, which doesn't fit
+// a goroutine-based API; polling keeps WatchPorts usable from any
+// goroutine at the cost of sub-second notification latency.
+func watchPorts(ctx context.Context) (<-chan PortEvent, error) {
+	events := make(chan PortEvent)
+	go func() {
+		defer close(events)
+		seen := map[string]PortInfo{}
+		if ports, err := listPorts(); err == nil {
+			for _, p := range ports {
+				seen[p.Name] = p
+			}
+		}
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := map[string]PortInfo{}
+				ports, err := listPorts()
+				if err != nil {
+					continue
+				}
+				for _, p := range ports {
+					current[p.Name] = p
+					if _, ok := seen[p.Name]; !ok {
+						select {
+						case events <- PortEvent{Type: PortAttached, Port: p}:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+				for name, p := range seen {
+					if _, ok := current[name]; !ok {
+						select {
+						case events <- PortEvent{Type: PortDetached, Port: p}:
+						case <-ctx.Done():
+							return
+						}
+					}
+				}
+				seen = current
+			}
+		}
+	}()
+	return events, nil
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"sync"
 	"time"
 )
 
@@ -51,25 +52,40 @@ type Config struct {
 	// Number of stop bits to use. Default is 1 (1 stop bit).
 	StopBits StopBits
 
-	// RTSFlowControl bool
-	// DTRFlowControl bool
-	// XONFlowControl bool
+	// RTSFlowControl enables RTS/CTS hardware flow control.
+	RTSFlowControl bool
+
+	// DTRFlowControl enables DTR/DSR hardware flow control.
+	DTRFlowControl bool
+
+	// XONFlowControl enables XON/XOFF software flow control.
+	XONFlowControl bool
 
 	// CRLFTranslate bool
 }
 
 type SerialPort struct {
-	port          io.ReadWriteCloser
-	name          string
-	baud          int
-	eol           uint8
-	rxChar        chan byte
-	closeReqChann chan bool
-	closeAckChann chan error
-	buff          *bytes.Buffer
-	logger        *log.Logger
-	portIsOpen    bool
-	Verbose       bool
+	port            Transport
+	customTransport Transport
+	name            string
+	baud            int
+	eol             uint8
+	rxChar          chan byte
+	closeReqChann   chan bool
+	closeAckChann   chan error
+	buff            *bytes.Buffer
+	buffMu          sync.Mutex
+	lines           chan string
+	linesMu         sync.Mutex
+	linesClosed     bool
+	quit            chan struct{}
+	wg              sync.WaitGroup
+	logger          *log.Logger
+	portIsOpen      bool
+	Verbose         bool
+	rtsFlowControl  bool
+	dtrFlowControl  bool
+	xonFlowControl  bool
 	// openPort      func(port string, baud int) (io.ReadWriteCloser, error)
 }
 
@@ -111,13 +127,13 @@ func New() *SerialPort {
 	}
 }
 
-//Create a connection with I/O device by serial com port
-//@name: COM1 - COM24
-//@baud: 9600/38400/115200...
-//@databits:"5"/"6"/"7"/"8"   DefaultSize = 8
-//@timeout: 1m = 1minutes / 1h = 1 hour  according to parser rule of offical TIME package
-//@parity: "N" = none / "O" = odd / "E" = even / "M" = mark / "S" = space
-//@stopbit: "1" = 1 bit / "1.5" = 1 half bit / "2" = 2 bits
+// Create a connection with I/O device by serial com port
+// @name: COM1 - COM24
+// @baud: 9600/38400/115200...
+// @databits:"5"/"6"/"7"/"8"   DefaultSize = 8
+// @timeout: 1m = 1minutes / 1h = 1 hour  according to parser rule of offical TIME package
+// @parity: "N" = none / "O" = odd / "E" = even / "M" = mark / "S" = space
+// @stopbit: "1" = 1 bit / "1.5" = 1 half bit / "2" = 2 bits
 func (sp *SerialPort) Open(name string, baud int, databits, timeout, parity, stopbit string) error {
 	// Check if port is open
 	if sp.portIsOpen {
@@ -186,26 +202,37 @@ func (sp *SerialPort) Open(name string, baud int, databits, timeout, parity, sto
 		databit = DefaultSize
 	}
 
-	// Open serial port
-	comPort, err := openPort(serialCfg.Name,
-		serialCfg.Baud,
-		databit,
-		serialCfg.Parity,
-		serialCfg.StopBits,
-		serialCfg.ReadTimeout)
-	if err != nil {
+	serialCfg.Size = databit
+	serialCfg.RTSFlowControl = sp.rtsFlowControl
+	serialCfg.DTRFlowControl = sp.dtrFlowControl
+	serialCfg.XONFlowControl = sp.xonFlowControl
+
+	// Open the port through whichever Transport is in play: the local
+	// serial driver by default, or one set with SetTransport (e.g. a
+	// TCPTransport/RFC2217Transport pointed at a networked serial port).
+	transport := sp.customTransport
+	if transport == nil {
+		transport = &localTransport{}
+	}
+	if err := transport.Open(serialCfg); err != nil {
 		return fmt.Errorf("Unable to open port \"%s\" - %s", name, err)
 	}
 
 	// Open port succesfull
 	sp.name = name
 	sp.baud = baud
-	sp.port = comPort
+	sp.port = transport
 	sp.portIsOpen = true
 	sp.buff.Reset()
 	// Open channels
 	sp.rxChar = make(chan byte)
+	sp.quit = make(chan struct{})
+	sp.linesMu.Lock()
+	sp.lines = make(chan string, 16)
+	sp.linesClosed = false
+	sp.linesMu.Unlock()
 	// Enable threads
+	sp.wg.Add(2)
 	go sp.readSerialPort()
 	go sp.processSerialPort()
 	sp.logger.SetPrefix(fmt.Sprintf("[%s] ", sp.name))
@@ -217,9 +244,22 @@ func (sp *SerialPort) Open(name string, baud int, databits, timeout, parity, sto
 func (sp *SerialPort) Close() error {
 	if sp.portIsOpen {
 		sp.portIsOpen = false
+		close(sp.quit)
+		err := sp.port.Close()
+		// Wait for readSerialPort/processSerialPort to observe quit and
+		// return before reusing sp.buff/sp.rxChar/sp.lines (e.g. the next
+		// Open, as modbus's idle-timeout reopen does immediately after
+		// Close): otherwise a send in flight on one of those channels
+		// could race the next Open resetting them, or land on rxChar
+		// right as it's closed below.
+		sp.wg.Wait()
 		close(sp.rxChar)
+		sp.linesMu.Lock()
+		sp.linesClosed = true
+		close(sp.lines)
+		sp.linesMu.Unlock()
 		sp.log("Serial port %s closed", sp.name)
-		return sp.port.Close()
+		return err
 	}
 	return nil
 }
@@ -269,7 +309,7 @@ func (sp *SerialPort) Printf(format string, args ...interface{}) error {
 	return sp.Print(str)
 }
 
-//This method send a binary file trough the serial port. If EnableLog is active then this method will log file related data.
+// This method send a binary file trough the serial port. If EnableLog is active then this method will log file related data.
 func (sp *SerialPort) SendFile(filepath string) error {
 	// Aux Vars
 	sentBytes := 0
@@ -309,6 +349,8 @@ func (sp *SerialPort) SendFile(filepath string) error {
 // Read the first byte of the serial buffer.
 func (sp *SerialPort) Read() (byte, error) {
 	if sp.portIsOpen {
+		sp.buffMu.Lock()
+		defer sp.buffMu.Unlock()
 		return sp.buff.ReadByte()
 	} else {
 		return 0x00, fmt.Errorf("Serial port is not open")
@@ -323,7 +365,9 @@ func (sp *SerialPort) Read() (byte, error) {
 // The text returned from ReadLine does not include the line end ("\r\n" or '\n').
 func (sp *SerialPort) ReadLine() (string, error) {
 	if sp.portIsOpen {
+		sp.buffMu.Lock()
 		line, err := sp.buff.ReadString(sp.eol)
+		sp.buffMu.Unlock()
 		if err != nil {
 			return "", err
 		} else {
@@ -336,51 +380,95 @@ func (sp *SerialPort) ReadLine() (string, error) {
 }
 
 // Wait for a defined regular expression for a defined amount of time.
+//
+// Unlike earlier versions, this no longer polls ReadLine in a loop: it
+// blocks on the same completed-line channel that feeds WaitForAnyRegex and
+// Expect, so a match is seen as soon as processSerialPort assembles it.
 func (sp *SerialPort) WaitForRegexTimeout(exp string, timeout time.Duration) (string, error) {
+	_, match, err := sp.waitForRegexes([]string{exp}, timeout)
+	return match, err
+}
 
-	if sp.portIsOpen {
-		//Decode received data
-		timeExpired := false
-
-		regExpPatttern := regexp.MustCompile(exp)
-
-		//Timeout structure
-		c1 := make(chan string, 1)
-		go func() {
-			sp.log("INF >> Waiting for RegExp: \"%s\"", exp)
-			result := []string{}
-			for !timeExpired {
-				time.Sleep(time.Millisecond * 50)
-				line, err := sp.ReadLine()
-				if err != nil {
-					// Do nothing
-				} else {
-					result = regExpPatttern.FindAllString(line, -1)
-					if len(result) > 0 {
-						c1 <- result[0]
-						break
-					}
+// WaitForAnyRegex waits for the first line matching any of patterns,
+// returning the pattern that matched and the matched substring. It is
+// useful for expect-style scripts that must branch on which of several
+// expected responses arrived first (e.g. "OK" vs "ERROR").
+func (sp *SerialPort) WaitForAnyRegex(patterns ...string) (string, string, error) {
+	return sp.waitForRegexes(patterns, 0)
+}
+
+// waitForRegexes is the shared implementation behind WaitForRegexTimeout
+// and WaitForAnyRegex. timeout of 0 means wait forever.
+func (sp *SerialPort) waitForRegexes(patterns []string, timeout time.Duration) (string, string, error) {
+	if !sp.portIsOpen {
+		return "", "", fmt.Errorf("Serial port is not open")
+	}
+
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, exp := range patterns {
+		compiled[i] = regexp.MustCompile(exp)
+		sp.log("INF >> Waiting for RegExp: \"%s\"", exp)
+	}
+
+	var timeoutChann <-chan time.Time
+	if timeout > 0 {
+		timeoutChann = time.After(timeout)
+	}
+
+	for {
+		select {
+		case line, ok := <-sp.lines:
+			if !ok {
+				return "", "", fmt.Errorf("Serial port is not open")
+			}
+			for i, re := range compiled {
+				if result := re.FindString(line); result != "" {
+					sp.log("INF >> The RegExp: \"%s\"", patterns[i])
+					sp.log("INF >> Has been matched: \"%s\"", result)
+					return patterns[i], result, nil
 				}
 			}
-		}()
-		select {
-		case data := <-c1:
-			sp.log("INF >> The RegExp: \"%s\"", exp)
-			sp.log("INF >> Has been matched: \"%s\"", data)
-			return data, nil
-		case <-time.After(timeout):
-			timeExpired = true
-			sp.log("INF >> Unable to match RegExp: \"%s\"", exp)
-			return "", fmt.Errorf("Timeout expired")
+		case <-timeoutChann:
+			sp.log("INF >> Unable to match RegExp(s): %v", patterns)
+			return "", "", fmt.Errorf("Timeout expired")
 		}
-	} else {
-		return "", fmt.Errorf("Serial port is not open")
 	}
-	return "", nil
+}
+
+// Step describes one exchange in an Expect script: wait for Expect to
+// appear on the line, then optionally Send a reply, similar to classic
+// expect/chat scripting.
+type Step struct {
+	Expect  string        // regular expression to wait for
+	Send    string        // text to print after Expect matches, if not empty
+	Timeout time.Duration // per-step timeout, defaults to 5s if zero
+}
+
+// Expect runs sequence in order, waiting for each Step's Expect pattern
+// and printing its Send text before moving to the next step. It returns
+// as soon as a step fails to match within its timeout.
+func (sp *SerialPort) Expect(sequence []Step) error {
+	for _, step := range sequence {
+		timeout := step.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+		if _, err := sp.WaitForRegexTimeout(step.Expect, timeout); err != nil {
+			return err
+		}
+		if step.Send != "" {
+			if err := sp.Print(step.Send); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // Available return the total number of available unread bytes on the serial buffer.
 func (sp *SerialPort) Available() int {
+	sp.buffMu.Lock()
+	defer sp.buffMu.Unlock()
 	return sp.buff.Len()
 }
 
@@ -389,46 +477,173 @@ func (sp *SerialPort) EOL(c byte) {
 	sp.eol = c
 }
 
+// SetFlowControl selects the flow control mode(s) used the next time Open
+// is called; it has no effect on an already-open port.
+func (sp *SerialPort) SetFlowControl(rts, dtr, xon bool) {
+	sp.rtsFlowControl = rts
+	sp.dtrFlowControl = dtr
+	sp.xonFlowControl = xon
+}
+
+// SetTransport makes the next call to Open drive t instead of the local
+// serial driver, e.g. a TCPTransport or RFC2217Transport pointed at a
+// networked serial port. It must be called before Open.
+func (sp *SerialPort) SetTransport(t Transport) {
+	sp.customTransport = t
+}
+
+// lineController is implemented by transports that can drive and read the
+// serial line-control signals (RTS/DTR out, CTS/DSR/DCD/RI in) and send a
+// break condition. Not every transport backs a real UART, so SerialPort
+// type-asserts for it rather than requiring it.
+type lineController interface {
+	SetRTS(bool) error
+	SetDTR(bool) error
+	GetCTS() (bool, error)
+	GetDSR() (bool, error)
+	GetDCD() (bool, error)
+	GetRI() (bool, error)
+	SendBreak(time.Duration) error
+}
+
+// ErrFlowControlUnsupported is returned by the line-control methods when
+// the underlying transport cannot drive modem control lines.
+var ErrFlowControlUnsupported error = errors.New("serial: line control is not supported by this transport")
+
+// SetRTS drives the RTS line, e.g. to reset an Arduino-style board.
+func (sp *SerialPort) SetRTS(state bool) error {
+	lc, ok := sp.port.(lineController)
+	if !ok {
+		return ErrFlowControlUnsupported
+	}
+	return lc.SetRTS(state)
+}
+
+// SetDTR drives the DTR line.
+func (sp *SerialPort) SetDTR(state bool) error {
+	lc, ok := sp.port.(lineController)
+	if !ok {
+		return ErrFlowControlUnsupported
+	}
+	return lc.SetDTR(state)
+}
+
+// GetCTS reads the CTS modem control line.
+func (sp *SerialPort) GetCTS() (bool, error) {
+	lc, ok := sp.port.(lineController)
+	if !ok {
+		return false, ErrFlowControlUnsupported
+	}
+	return lc.GetCTS()
+}
+
+// GetDSR reads the DSR modem control line.
+func (sp *SerialPort) GetDSR() (bool, error) {
+	lc, ok := sp.port.(lineController)
+	if !ok {
+		return false, ErrFlowControlUnsupported
+	}
+	return lc.GetDSR()
+}
+
+// GetDCD reads the DCD (carrier detect) modem control line.
+func (sp *SerialPort) GetDCD() (bool, error) {
+	lc, ok := sp.port.(lineController)
+	if !ok {
+		return false, ErrFlowControlUnsupported
+	}
+	return lc.GetDCD()
+}
+
+// GetRI reads the RI (ring indicator) modem control line.
+func (sp *SerialPort) GetRI() (bool, error) {
+	lc, ok := sp.port.(lineController)
+	if !ok {
+		return false, ErrFlowControlUnsupported
+	}
+	return lc.GetRI()
+}
+
+// SendBreak asserts a break condition on the line for duration.
+func (sp *SerialPort) SendBreak(duration time.Duration) error {
+	lc, ok := sp.port.(lineController)
+	if !ok {
+		return ErrFlowControlUnsupported
+	}
+	return lc.SendBreak(duration)
+}
+
 /*******************************************************************************************
 ******************************   PRIVATE FUNCTIONS  ****************************************
 *******************************************************************************************/
 
 func (sp *SerialPort) readSerialPort() {
+	defer sp.wg.Done()
 	rxBuff := make([]byte, 256)
-	for sp.portIsOpen {
+	quit := sp.quit
+	for {
+		select {
+		case <-quit:
+			return
+		default:
+		}
 		n, _ := sp.port.Read(rxBuff)
 		// Write data to serial buffer
+		sp.buffMu.Lock()
 		sp.buff.Write(rxBuff[:n])
+		sp.buffMu.Unlock()
 		for _, b := range rxBuff[:n] {
-			if sp.portIsOpen {
-				sp.rxChar <- b
+			select {
+			case sp.rxChar <- b:
+			case <-quit:
+				return
 			}
 		}
 	}
 }
 
 func (sp *SerialPort) processSerialPort() {
+	defer sp.wg.Done()
 	screenBuff := make([]byte, 0)
-	var lastRxByte byte
+	quit := sp.quit
 	for {
-		if sp.portIsOpen {
-			lastRxByte = <-sp.rxChar
-			// Print received lines
-			switch lastRxByte {
-			case sp.eol:
-				// EOL - Print received data
-				sp.log("Rx << %s", string(append(screenBuff, lastRxByte)))
-				screenBuff = make([]byte, 0) //Clean buffer
-				break
-			default:
-				screenBuff = append(screenBuff, lastRxByte)
-			}
-		} else {
+		var lastRxByte byte
+		select {
+		case lastRxByte = <-sp.rxChar:
+		case <-quit:
+			return
+		}
+		// Print received lines
+		switch lastRxByte {
+		case sp.eol:
+			// EOL - Print received data
+			sp.log("Rx << %s", string(append(screenBuff, lastRxByte)))
+			sp.publishLine(removeEOL(string(screenBuff)))
+			screenBuff = make([]byte, 0) //Clean buffer
 			break
+		default:
+			screenBuff = append(screenBuff, lastRxByte)
 		}
 	}
 }
 
+// publishLine hands a completed line to WaitForRegexTimeout/WaitForAnyRegex
+// callers. It never blocks: if nobody is waiting and the channel is full,
+// the line is dropped from this feed (it is still available through
+// ReadLine via sp.buff). It is a no-op once Close has closed the channel,
+// so a line completing concurrently with Close cannot send on it.
+func (sp *SerialPort) publishLine(line string) {
+	sp.linesMu.Lock()
+	defer sp.linesMu.Unlock()
+	if sp.linesClosed {
+		return
+	}
+	select {
+	case sp.lines <- line:
+	default:
+	}
+}
+
 func (sp *SerialPort) log(format string, a ...interface{}) {
 	if sp.Verbose {
 		sp.logger.Printf(format, a...)
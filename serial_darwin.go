@@ -0,0 +1,140 @@
+//go:build darwin
+// +build darwin
+
+package serial
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ioctl request numbers follow Darwin's sys/ttycom.h and sys/filio.h,
+// which encode direction and size into the request differently from Linux.
+const (
+	tiocmGet = 0x4004746a
+	tiocmBis = 0x8004746c
+	tiocmBic = 0x8004746b
+
+	tiocsbrk = 0x2000747b
+	tioccbrk = 0x2000747a
+
+	tiocgeta = 0x40487413 // TCGETA-equivalent (struct termios)
+	tiocseta = 0x80487414 // TCSETA-equivalent (struct termios)
+)
+
+const (
+	cs5     = 0x0
+	cs6     = 0x100
+	cs7     = 0x200
+	cs8     = 0x300
+	cstopb  = 0x400
+	cread   = 0x800
+	parenb  = 0x1000
+	parodd  = 0x2000
+	clocal  = 0x8000
+	crtscts = 0x30000
+
+	ixon  = 0x0200
+	ixoff = 0x0400
+
+	nccs  = 20
+	vmin  = 16
+	vtime = 17
+)
+
+// termios mirrors Darwin's struct termios (sys/termios.h), where the flag
+// fields are unsigned long (8 bytes on 64-bit Darwin) rather than the
+// uint32 Linux uses.
+type termios struct {
+	Iflag  uint64
+	Oflag  uint64
+	Cflag  uint64
+	Lflag  uint64
+	Cc     [nccs]uint8
+	Ispeed uint64
+	Ospeed uint64
+}
+
+var baudRates = map[int]uint64{
+	50: 50, 75: 75, 110: 110, 134: 134, 150: 150, 200: 200,
+	300: 300, 600: 600, 1200: 1200, 1800: 1800, 2400: 2400, 4800: 4800,
+	9600: 9600, 19200: 19200, 38400: 38400, 57600: 57600, 115200: 115200,
+	230400: 230400, 460800: 460800, 921600: 921600,
+}
+
+// openPort opens name as a raw, non-canonical serial device. Unlike Linux,
+// Darwin's termios takes the literal baud rate rather than a Bxxx symbol.
+func openPort(name string, baud int, databits byte, parity Parity, stopBits StopBits, readTimeout time.Duration, rtsFlow, dtrFlow, xonFlow bool) (io.ReadWriteCloser, error) {
+	fd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &posixPort{fd: fd}
+
+	var t termios
+	if err := p.ioctl(tiocgeta, uintptr(unsafe.Pointer(&t))); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: TIOCGETA: %w", err)
+	}
+
+	rate, ok := baudRates[baud]
+	if !ok {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: unsupported baud rate %d", baud)
+	}
+	t.Ispeed = rate
+	t.Ospeed = rate
+	t.Cflag = cread | clocal
+
+	switch databits {
+	case 5:
+		t.Cflag |= cs5
+	case 6:
+		t.Cflag |= cs6
+	case 7:
+		t.Cflag |= cs7
+	default:
+		t.Cflag |= cs8
+	}
+
+	switch parity {
+	case ParityOdd:
+		t.Cflag |= parenb | parodd
+	case ParityEven, ParityMark, ParitySpace:
+		// Darwin's termios has no CMSPAR equivalent; mark/space parity
+		// falls back to plain even parity.
+		t.Cflag |= parenb
+	}
+
+	if stopBits == Stop2 {
+		t.Cflag |= cstopb
+	}
+	if rtsFlow {
+		t.Cflag |= crtscts
+	}
+	if xonFlow {
+		t.Iflag |= ixon | ixoff
+	}
+
+	t.Lflag = 0
+	t.Oflag = 0
+
+	vmin8, vtime8 := posixTimeoutValues(readTimeout)
+	t.Cc[vmin] = vmin8
+	t.Cc[vtime] = vtime8
+
+	if err := p.ioctl(tiocseta, uintptr(unsafe.Pointer(&t))); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: TIOCSETA: %w", err)
+	}
+
+	if dtrFlow {
+		_ = p.SetDTR(true)
+	}
+
+	return p, nil
+}
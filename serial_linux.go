@@ -0,0 +1,154 @@
+//go:build linux
+// +build linux
+
+package serial
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ioctl request numbers and termios layout below follow
+// asm-generic/ioctls.h and asm-generic/termbits.h.
+const (
+	tcgets = 0x5401
+	tcsets = 0x5402
+
+	tiocmGet = 0x5415
+	tiocmBis = 0x5416
+	tiocmBic = 0x5417
+
+	tiocsbrk = 0x5427
+	tioccbrk = 0x5428
+)
+
+const (
+	cs5     = 0x0
+	cs6     = 0x10
+	cs7     = 0x20
+	cs8     = 0x30
+	csize   = 0x30
+	cstopb  = 0x40
+	cread   = 0x80
+	parenb  = 0x100
+	parodd  = 0x200
+	clocal  = 0x800
+	cmspar  = 0x40000000
+	crtscts = 0x80000000
+
+	ixon  = 0x0400
+	ixoff = 0x1000
+
+	ncc = 19
+	// Indexes into termios.Cc.
+	vmin  = 6
+	vtime = 5
+)
+
+// termios mirrors struct termios2 as defined by asm-generic/termbits.h.
+type termios struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Line   uint8
+	Cc     [ncc]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+// baudRates maps the handful of rates this library cares about to their
+// Bxxx termios constant; openPort rejects any rate not listed here with an
+// "unsupported baud rate" error.
+var baudRates = map[int]uint32{
+	50: 0x1, 75: 0x2, 110: 0x3, 134: 0x4, 150: 0x5, 200: 0x6,
+	300: 0x7, 600: 0x8, 1200: 0x9, 1800: 0xA, 2400: 0xB, 4800: 0xC,
+	9600: 0xD, 19200: 0xE, 38400: 0xF, 57600: 0x1001, 115200: 0x1002,
+	230400: 0x1003, 460800: 0x1004, 921600: 0x1007,
+}
+
+// openPort opens name as a raw, non-canonical serial device and configures
+// it per the Config fields rebuilt by SerialPort.Open.
+func openPort(name string, baud int, databits byte, parity Parity, stopBits StopBits, readTimeout time.Duration, rtsFlow, dtrFlow, xonFlow bool) (io.ReadWriteCloser, error) {
+	fd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &posixPort{fd: fd}
+
+	var t termios
+	if err := p.ioctl(tcgets, uintptr(unsafe.Pointer(&t))); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: TCGETS: %w", err)
+	}
+
+	rate, ok := baudRates[baud]
+	if !ok {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: unsupported baud rate %d", baud)
+	}
+	t.Ispeed = rate
+	t.Ospeed = rate
+	t.Cflag = rate | cread | clocal
+
+	switch databits {
+	case 5:
+		t.Cflag |= cs5
+	case 6:
+		t.Cflag |= cs6
+	case 7:
+		t.Cflag |= cs7
+	default:
+		t.Cflag |= cs8
+	}
+
+	switch parity {
+	case ParityOdd:
+		t.Cflag |= parenb | parodd
+	case ParityEven:
+		t.Cflag |= parenb
+	case ParityMark:
+		t.Cflag |= parenb | parodd | cmspar
+	case ParitySpace:
+		t.Cflag |= parenb | cmspar
+	}
+
+	if stopBits == Stop2 {
+		t.Cflag |= cstopb
+	}
+
+	if rtsFlow {
+		t.Cflag |= crtscts
+	}
+	if xonFlow {
+		t.Iflag |= ixon | ixoff
+	}
+
+	// Raw mode: no line editing, no signal generation, no output processing.
+	t.Lflag = 0
+	t.Oflag = 0
+
+	vmin8, vtime8 := posixTimeoutValues(readTimeout)
+	t.Cc[vmin] = vmin8
+	t.Cc[vtime] = vtime8
+
+	if err := p.ioctl(tcsets, uintptr(unsafe.Pointer(&t))); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("serial: TCSETS: %w", err)
+	}
+
+	if dtrFlow {
+		// DTR/DSR flow control has no dedicated termios bit on Linux; the
+		// driver honors it once DTR is asserted and CLOCAL is left unset,
+		// but CLOCAL is required above to open without carrier, so this
+		// is best-effort: assert DTR and rely on the application-level
+		// handshake instead.
+		_ = p.SetDTR(true)
+	}
+
+	return p, nil
+}
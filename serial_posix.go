@@ -0,0 +1,97 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package serial
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// TIOCM_* bit values are identical across Linux and the BSD-derived
+// termios family, so they live here rather than in each platform file.
+const (
+	tiocmRTS = 0x004
+	tiocmDTR = 0x002
+	tiocmCTS = 0x020
+	tiocmDSR = 0x100
+	tiocmCD  = 0x040
+	tiocmRI  = 0x080
+)
+
+// posixPort is the io.ReadWriteCloser + lineController backing a real
+// UART on Linux and Darwin. The termios setup that produces an fd is
+// platform-specific (see serial_linux.go / serial_darwin.go); everything
+// that only needs a raw fd and an ioctl number lives here.
+type posixPort struct {
+	fd int
+}
+
+func (p *posixPort) Read(b []byte) (int, error) {
+	return syscall.Read(p.fd, b)
+}
+
+func (p *posixPort) Write(b []byte) (int, error) {
+	return syscall.Write(p.fd, b)
+}
+
+func (p *posixPort) Close() error {
+	return syscall.Close(p.fd)
+}
+
+func (p *posixPort) ioctl(req uintptr, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(p.fd), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (p *posixPort) modemBits() (uint32, error) {
+	var bits uint32
+	if err := p.ioctl(tiocmGet, uintptr(unsafe.Pointer(&bits))); err != nil {
+		return 0, err
+	}
+	return bits, nil
+}
+
+func (p *posixPort) setModemBit(bit uint32, state bool) error {
+	req := uintptr(tiocmBic)
+	if state {
+		req = uintptr(tiocmBis)
+	}
+	bits := bit
+	return p.ioctl(req, uintptr(unsafe.Pointer(&bits)))
+}
+
+func (p *posixPort) SetRTS(state bool) error { return p.setModemBit(tiocmRTS, state) }
+func (p *posixPort) SetDTR(state bool) error { return p.setModemBit(tiocmDTR, state) }
+
+func (p *posixPort) GetCTS() (bool, error) { return p.modemBitSet(tiocmCTS) }
+func (p *posixPort) GetDSR() (bool, error) { return p.modemBitSet(tiocmDSR) }
+func (p *posixPort) GetDCD() (bool, error) { return p.modemBitSet(tiocmCD) }
+func (p *posixPort) GetRI() (bool, error)  { return p.modemBitSet(tiocmRI) }
+
+func (p *posixPort) modemBitSet(bit uint32) (bool, error) {
+	bits, err := p.modemBits()
+	if err != nil {
+		return false, err
+	}
+	return bits&bit != 0, nil
+}
+
+// SendBreak asserts a break condition for duration using TIOCSBRK/TIOCCBRK,
+// rather than the "0" argument form of ioctl(TCSBRKP) whose duration units
+// are driver-defined and inconsistent across UART drivers.
+func (p *posixPort) SendBreak(duration time.Duration) error {
+	if err := p.ioctl(tiocsbrk, 0); err != nil {
+		return fmt.Errorf("serial: asserting break: %w", err)
+	}
+	time.Sleep(duration)
+	if err := p.ioctl(tioccbrk, 0); err != nil {
+		return fmt.Errorf("serial: clearing break: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+// noopTransport is a minimal Transport that does nothing, used to drive
+// SerialPort methods in tests without a real port or network connection.
+type noopTransport struct{}
+
+func (noopTransport) Open(Config) error           { return nil }
+func (noopTransport) Read(p []byte) (int, error)  { return 0, nil }
+func (noopTransport) Write(p []byte) (int, error) { return len(p), nil }
+func (noopTransport) Close() error                { return nil }
+func (noopTransport) SetBaud(int) error           { return nil }
+func (noopTransport) SetParity(Parity) error      { return nil }
+
+// TestCloseRaceWithPublishLine exercises Close racing publishLine, which
+// used to panic with "send on closed channel" when a line finished
+// completing on sp.lines concurrently with the port closing. Run with
+// -race to catch a regression.
+func TestCloseRaceWithPublishLine(t *testing.T) {
+	sp := &SerialPort{
+		port:       noopTransport{},
+		portIsOpen: true,
+		rxChar:     make(chan byte),
+		lines:      make(chan string, 4),
+		quit:       make(chan struct{}),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			sp.publishLine("line")
+		}
+	}()
+
+	time.Sleep(time.Millisecond)
+	if err := sp.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	<-done
+}
@@ -0,0 +1,238 @@
+//go:build windows
+// +build windows
+
+package serial
+
+import (
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	modkernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procCreateFileW        = modkernel32.NewProc("CreateFileW")
+	procGetCommState       = modkernel32.NewProc("GetCommState")
+	procSetCommState       = modkernel32.NewProc("SetCommState")
+	procSetCommTimeouts    = modkernel32.NewProc("SetCommTimeouts")
+	procEscapeCommFunction = modkernel32.NewProc("EscapeCommFunction")
+	procGetCommModemStatus = modkernel32.NewProc("GetCommModemStatus")
+	procSetCommBreak       = modkernel32.NewProc("SetCommBreak")
+	procClearCommBreak     = modkernel32.NewProc("ClearCommBreak")
+)
+
+// EscapeCommFunction function codes.
+const (
+	setRTS = 3
+	clrRTS = 4
+	setDTR = 5
+	clrDTR = 6
+)
+
+// GetCommModemStatus bits.
+const (
+	msCTSOn  = 0x0010
+	msDSROn  = 0x0020
+	msRingOn = 0x0040
+	msRLSDOn = 0x0080 // carrier detect
+)
+
+// dcb mirrors the Win32 DCB struct (minus the packed bitfield, which is
+// handled via the Flags uint32 and the helper constants below).
+type dcb struct {
+	DCBlength  uint32
+	BaudRate   uint32
+	Flags      uint32
+	wReserved  uint16
+	XonLim     uint16
+	XoffLim    uint16
+	ByteSize   byte
+	Parity     byte
+	StopBits   byte
+	XonChar    byte
+	XoffChar   byte
+	ErrorChar  byte
+	EofChar    byte
+	EvtChar    byte
+	wReserved1 uint16
+}
+
+const (
+	dcbBinary              = 1 << 0
+	dcbParity              = 1 << 1
+	dcbOutxCtsFlow         = 1 << 2
+	dcbOutxDsrFlow         = 1 << 3
+	dcbDtrControlEnable    = 1 << 4 // of the 2-bit fDtrControl field
+	dcbRtsControlEnable    = 1 << 12
+	dcbRtsControlHandshake = 2 << 12
+	dcbOutX                = 1 << 8
+	dcbInX                 = 1 << 9
+)
+
+type commTimeouts struct {
+	ReadIntervalTimeout         uint32
+	ReadTotalTimeoutMultiplier  uint32
+	ReadTotalTimeoutConstant    uint32
+	WriteTotalTimeoutMultiplier uint32
+	WriteTotalTimeoutConstant   uint32
+}
+
+// winPort is the io.ReadWriteCloser + lineController backing a real COM
+// port opened with CreateFile.
+type winPort struct {
+	handle syscall.Handle
+}
+
+func (p *winPort) Read(b []byte) (int, error) {
+	var n uint32
+	err := syscall.ReadFile(p.handle, b, &n, nil)
+	return int(n), err
+}
+
+func (p *winPort) Write(b []byte) (int, error) {
+	var n uint32
+	err := syscall.WriteFile(p.handle, b, &n, nil)
+	return int(n), err
+}
+
+func (p *winPort) Close() error {
+	return syscall.CloseHandle(p.handle)
+}
+
+func (p *winPort) escape(fn uintptr) error {
+	ok, _, err := procEscapeCommFunction.Call(uintptr(p.handle), fn)
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+func (p *winPort) SetRTS(state bool) error {
+	if state {
+		return p.escape(setRTS)
+	}
+	return p.escape(clrRTS)
+}
+
+func (p *winPort) SetDTR(state bool) error {
+	if state {
+		return p.escape(setDTR)
+	}
+	return p.escape(clrDTR)
+}
+
+func (p *winPort) modemStatus(bit uint32) (bool, error) {
+	var status uint32
+	ok, _, err := procGetCommModemStatus.Call(uintptr(p.handle), uintptr(unsafe.Pointer(&status)))
+	if ok == 0 {
+		return false, err
+	}
+	return status&bit != 0, nil
+}
+
+func (p *winPort) GetCTS() (bool, error) { return p.modemStatus(msCTSOn) }
+func (p *winPort) GetDSR() (bool, error) { return p.modemStatus(msDSROn) }
+func (p *winPort) GetDCD() (bool, error) { return p.modemStatus(msRLSDOn) }
+func (p *winPort) GetRI() (bool, error)  { return p.modemStatus(msRingOn) }
+
+func (p *winPort) SendBreak(duration time.Duration) error {
+	if ok, _, err := procSetCommBreak.Call(uintptr(p.handle)); ok == 0 {
+		return err
+	}
+	time.Sleep(duration)
+	if ok, _, err := procClearCommBreak.Call(uintptr(p.handle)); ok == 0 {
+		return err
+	}
+	return nil
+}
+
+// openPort opens name (e.g. "COM3") via CreateFile and configures the DCB
+// and timeouts per the requested Config.
+func openPort(name string, baud int, databits byte, parity Parity, stopBits StopBits, readTimeout time.Duration, rtsFlow, dtrFlow, xonFlow bool) (io.ReadWriteCloser, error) {
+	path, err := syscall.UTF16PtrFromString(`\\.\` + name)
+	if err != nil {
+		return nil, err
+	}
+	h, _, callErr := procCreateFileW.Call(
+		uintptr(unsafe.Pointer(path)),
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, 0,
+		syscall.OPEN_EXISTING,
+		0, 0,
+	)
+	if h == uintptr(syscall.InvalidHandle) {
+		return nil, fmt.Errorf("serial: CreateFile %q: %w", name, callErr)
+	}
+	handle := syscall.Handle(h)
+	p := &winPort{handle: handle}
+
+	var d dcb
+	d.DCBlength = uint32(unsafe.Sizeof(d))
+	if ok, _, err := procGetCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&d))); ok == 0 {
+		syscall.CloseHandle(handle)
+		return nil, fmt.Errorf("serial: GetCommState: %w", err)
+	}
+
+	d.BaudRate = uint32(baud)
+	d.ByteSize = databits
+	d.Flags = dcbBinary | dcbRtsControlEnable
+
+	switch parity {
+	case ParityOdd:
+		d.Parity = 1
+		d.Flags |= dcbParity
+	case ParityEven:
+		d.Parity = 2
+		d.Flags |= dcbParity
+	case ParityMark:
+		d.Parity = 3
+		d.Flags |= dcbParity
+	case ParitySpace:
+		d.Parity = 4
+		d.Flags |= dcbParity
+	default:
+		d.Parity = 0
+	}
+
+	switch stopBits {
+	case Stop1Half:
+		d.StopBits = 1
+	case Stop2:
+		d.StopBits = 2
+	default:
+		d.StopBits = 0
+	}
+
+	if rtsFlow {
+		d.Flags = d.Flags&^uint32(0x3000) | dcbOutxCtsFlow | dcbRtsControlHandshake
+	}
+	if dtrFlow {
+		d.Flags |= dcbOutxDsrFlow | dcbDtrControlEnable
+	}
+	if xonFlow {
+		d.Flags |= dcbOutX | dcbInX
+		d.XonChar = 0x11
+		d.XoffChar = 0x13
+	}
+
+	if ok, _, err := procSetCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&d))); ok == 0 {
+		syscall.CloseHandle(handle)
+		return nil, fmt.Errorf("serial: SetCommState: %w", err)
+	}
+
+	timeouts := commTimeouts{}
+	if readTimeout > 0 {
+		timeouts.ReadTotalTimeoutConstant = uint32(readTimeout / time.Millisecond)
+	} else {
+		timeouts.ReadIntervalTimeout = 0xFFFFFFFF // return immediately with whatever is buffered
+	}
+	if ok, _, err := procSetCommTimeouts.Call(uintptr(handle), uintptr(unsafe.Pointer(&timeouts))); ok == 0 {
+		syscall.CloseHandle(handle)
+		return nil, fmt.Errorf("serial: SetCommTimeouts: %w", err)
+	}
+
+	return p, nil
+}
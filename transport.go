@@ -0,0 +1,100 @@
+package serial
+
+import (
+	"io"
+	"time"
+)
+
+// Transport is the byte-level backend SerialPort drives. Extracting it
+// from the concrete local-serial driver lets the same SerialPort API run
+// over a physical port, a ser2net-style TCP gateway, or an RFC 2217
+// network serial port, which is also what makes the library testable
+// over a loopback net.Pipe without any real hardware.
+type Transport interface {
+	// Open configures and connects the transport per cfg. It is called
+	// once by SerialPort.Open.
+	Open(cfg Config) error
+	Read(p []byte) (int, error)
+	Write(p []byte) (int, error)
+	Close() error
+	// SetBaud changes the baud rate of an already-open transport.
+	SetBaud(baud int) error
+	// SetParity changes the parity of an already-open transport.
+	SetParity(parity Parity) error
+}
+
+// localTransport drives a real UART through the platform-specific
+// openPort (serial_linux.go / serial_darwin.go / serial_windows.go). It
+// is the Transport SerialPort.Open uses unless SetTransport was called.
+type localTransport struct {
+	cfg  Config
+	conn io.ReadWriteCloser
+}
+
+func (t *localTransport) Open(cfg Config) error {
+	conn, err := openPort(cfg.Name, cfg.Baud, cfg.Size, cfg.Parity, cfg.StopBits, cfg.ReadTimeout,
+		cfg.RTSFlowControl, cfg.DTRFlowControl, cfg.XONFlowControl)
+	if err != nil {
+		return err
+	}
+	t.cfg = cfg
+	t.conn = conn
+	return nil
+}
+
+func (t *localTransport) Read(p []byte) (int, error)  { return t.conn.Read(p) }
+func (t *localTransport) Write(p []byte) (int, error) { return t.conn.Write(p) }
+func (t *localTransport) Close() error                { return t.conn.Close() }
+
+// SetBaud reopens the port at the new baud rate; the platform drivers
+// have no ioctl to change it without a fresh termios/DCB setup.
+func (t *localTransport) SetBaud(baud int) error {
+	t.cfg.Baud = baud
+	return t.reopen()
+}
+
+// SetParity reopens the port with the new parity setting.
+func (t *localTransport) SetParity(parity Parity) error {
+	t.cfg.Parity = parity
+	return t.reopen()
+}
+
+func (t *localTransport) reopen() error {
+	if t.conn != nil {
+		t.conn.Close()
+	}
+	return t.Open(t.cfg)
+}
+
+// SetRTS, SetDTR, GetCTS, GetDSR, GetDCD, GetRI, and SendBreak delegate to
+// the underlying platform port when it implements lineController, so
+// SerialPort's line-control methods keep working unchanged now that
+// sp.port holds a Transport instead of the concrete platform type.
+func (t *localTransport) SetRTS(state bool) error         { return t.lineControl().SetRTS(state) }
+func (t *localTransport) SetDTR(state bool) error         { return t.lineControl().SetDTR(state) }
+func (t *localTransport) GetCTS() (bool, error)           { return t.lineControl().GetCTS() }
+func (t *localTransport) GetDSR() (bool, error)           { return t.lineControl().GetDSR() }
+func (t *localTransport) GetDCD() (bool, error)           { return t.lineControl().GetDCD() }
+func (t *localTransport) GetRI() (bool, error)            { return t.lineControl().GetRI() }
+func (t *localTransport) SendBreak(d time.Duration) error { return t.lineControl().SendBreak(d) }
+
+func (t *localTransport) lineControl() lineController {
+	if lc, ok := t.conn.(lineController); ok {
+		return lc
+	}
+	return noopLineController{}
+}
+
+// noopLineController backs lineControl() when the concrete platform port
+// doesn't support modem control lines, so localTransport can implement
+// lineController unconditionally instead of every caller type-asserting
+// twice.
+type noopLineController struct{}
+
+func (noopLineController) SetRTS(bool) error             { return ErrFlowControlUnsupported }
+func (noopLineController) SetDTR(bool) error             { return ErrFlowControlUnsupported }
+func (noopLineController) GetCTS() (bool, error)         { return false, ErrFlowControlUnsupported }
+func (noopLineController) GetDSR() (bool, error)         { return false, ErrFlowControlUnsupported }
+func (noopLineController) GetDCD() (bool, error)         { return false, ErrFlowControlUnsupported }
+func (noopLineController) GetRI() (bool, error)          { return false, ErrFlowControlUnsupported }
+func (noopLineController) SendBreak(time.Duration) error { return ErrFlowControlUnsupported }
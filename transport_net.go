@@ -0,0 +1,251 @@
+package serial
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrTransportControlUnsupported is returned by SetBaud/SetParity on a
+// Transport that has no way to reconfigure a remote device in-band, such
+// as a plain TCPTransport talking to a ser2net-style gateway whose serial
+// settings are fixed by its own configuration.
+var ErrTransportControlUnsupported error = fmt.Errorf("serial: this transport cannot reconfigure the remote device")
+
+// TCPTransport speaks raw TCP to a ser2net-style gateway that bridges a
+// TCP socket straight onto a serial port with no side-channel control
+// protocol: bytes written here arrive on the wire unmodified.
+type TCPTransport struct {
+	Addr        string // host:port of the gateway
+	DialTimeout time.Duration
+
+	conn net.Conn
+}
+
+// NewTCPTransport returns a TCPTransport that will dial addr on Open.
+func NewTCPTransport(addr string) *TCPTransport {
+	return &TCPTransport{Addr: addr}
+}
+
+func (t *TCPTransport) Open(cfg Config) error {
+	timeout := t.DialTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", t.Addr, timeout)
+	if err != nil {
+		return fmt.Errorf("serial: dialing %q: %w", t.Addr, err)
+	}
+	t.conn = conn
+	return nil
+}
+
+func (t *TCPTransport) Read(p []byte) (int, error)  { return t.conn.Read(p) }
+func (t *TCPTransport) Write(p []byte) (int, error) { return t.conn.Write(p) }
+func (t *TCPTransport) Close() error                { return t.conn.Close() }
+
+func (t *TCPTransport) SetBaud(baud int) error        { return ErrTransportControlUnsupported }
+func (t *TCPTransport) SetParity(parity Parity) error { return ErrTransportControlUnsupported }
+
+// Telnet/RFC 2217 constants.
+const (
+	telnetIAC  = 255
+	telnetSB   = 250
+	telnetSE   = 240
+	telnetWILL = 251
+	telnetDO   = 253
+
+	rfc2217ComPortOption = 44
+
+	rfc2217SetBaudrate = 1
+	rfc2217SetDatasize = 2
+	rfc2217SetParity   = 3
+	rfc2217SetStopsize = 4
+	rfc2217SetControl  = 5
+)
+
+// RFC2217Transport speaks the Telnet COM-Port-Control option (RFC 2217)
+// over a TCP connection, letting the same client code that drives a local
+// UART also drive a networked one whose baud/parity/etc. can be changed
+// on the fly instead of being fixed by the gateway's own config.
+type RFC2217Transport struct {
+	Addr        string
+	DialTimeout time.Duration
+
+	conn net.Conn
+}
+
+// NewRFC2217Transport returns an RFC2217Transport that will dial addr and
+// negotiate the COM-PORT-OPTION on Open.
+func NewRFC2217Transport(addr string) *RFC2217Transport {
+	return &RFC2217Transport{Addr: addr}
+}
+
+func (t *RFC2217Transport) Open(cfg Config) error {
+	timeout := t.DialTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", t.Addr, timeout)
+	if err != nil {
+		return fmt.Errorf("serial: dialing %q: %w", t.Addr, err)
+	}
+	t.conn = conn
+
+	// Announce that we will use the COM-PORT-OPTION; a conforming gateway
+	// (e.g. ser2net -C telnet,rfc2217) replies IAC DO COM-PORT-OPTION. The
+	// reply isn't validated byte-for-byte here: a gateway without 2217
+	// support will simply ignore the later subnegotiations.
+	if _, err := t.conn.Write([]byte{telnetIAC, telnetWILL, rfc2217ComPortOption}); err != nil {
+		t.conn.Close()
+		return err
+	}
+
+	if cfg.Baud != 0 {
+		if err := t.SetBaud(cfg.Baud); err != nil {
+			return err
+		}
+	}
+	if cfg.Parity != 0 {
+		if err := t.SetParity(cfg.Parity); err != nil {
+			return err
+		}
+	}
+	if cfg.StopBits != 0 {
+		if err := t.sendSubnegotiation(rfc2217SetStopsize, []byte{stopBitsToRFC2217(cfg.StopBits)}); err != nil {
+			return err
+		}
+	}
+	if cfg.Size != 0 {
+		if err := t.sendSubnegotiation(rfc2217SetDatasize, []byte{cfg.Size}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendSubnegotiation writes IAC SB COM-PORT-OPTION <cmd> <params> IAC SE,
+// escaping any literal 0xFF in params as RFC 854 requires.
+func (t *RFC2217Transport) sendSubnegotiation(cmd byte, params []byte) error {
+	frame := []byte{telnetIAC, telnetSB, rfc2217ComPortOption, cmd}
+	frame = append(frame, escapeIAC(params)...)
+	frame = append(frame, telnetIAC, telnetSE)
+	_, err := t.conn.Write(frame)
+	return err
+}
+
+// SetBaud sends a SET-BAUDRATE subnegotiation, changing the remote COM
+// port's speed without reconnecting.
+func (t *RFC2217Transport) SetBaud(baud int) error {
+	params := []byte{byte(baud >> 24), byte(baud >> 16), byte(baud >> 8), byte(baud)}
+	return t.sendSubnegotiation(rfc2217SetBaudrate, params)
+}
+
+// SetParity sends a SET-PARITY subnegotiation.
+func (t *RFC2217Transport) SetParity(parity Parity) error {
+	var code byte
+	switch parity {
+	case ParityOdd:
+		code = 2
+	case ParityEven:
+		code = 3
+	case ParityMark:
+		code = 4
+	case ParitySpace:
+		code = 5
+	default:
+		code = 1
+	}
+	return t.sendSubnegotiation(rfc2217SetParity, []byte{code})
+}
+
+func stopBitsToRFC2217(s StopBits) byte {
+	switch s {
+	case Stop1Half:
+		return 3
+	case Stop2:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func escapeIAC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		out = append(out, b)
+		if b == telnetIAC {
+			out = append(out, telnetIAC)
+		}
+	}
+	return out
+}
+
+// Write escapes any literal IAC byte in p before sending it on the data
+// channel, as RFC 2217 requires for the payload stream itself (not just
+// subnegotiations).
+func (t *RFC2217Transport) Write(p []byte) (int, error) {
+	if _, err := t.conn.Write(escapeIAC(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read strips IAC-escaped bytes and in-band telnet commands (notably the
+// server's own COM-PORT-OPTION notifications) out of the stream, handing
+// the caller only serial payload bytes.
+func (t *RFC2217Transport) Read(p []byte) (int, error) {
+	raw := make([]byte, len(p))
+	n, err := t.conn.Read(raw)
+	if n == 0 {
+		return 0, err
+	}
+
+	out := make([]byte, 0, n)
+	for i := 0; i < n; i++ {
+		b := raw[i]
+		if b != telnetIAC {
+			out = append(out, b)
+			continue
+		}
+		if i+1 >= n {
+			break // command split across reads; drop the trailing IAC
+		}
+		switch raw[i+1] {
+		case telnetIAC:
+			out = append(out, telnetIAC)
+			i++
+		case telnetSB:
+			// Only the literal IAC SE pair ends a subnegotiation; a bare
+			// SE-valued byte can legitimately appear in its parameters.
+			// A doubled IAC within those parameters is an escaped literal
+			// 0xFF, not the start of the terminator.
+			j := i + 2
+			found := false
+			for j < n {
+				if raw[j] == telnetIAC && j+1 < n {
+					if raw[j+1] == telnetSE {
+						i = j + 1
+						found = true
+						break
+					}
+					if raw[j+1] == telnetIAC {
+						j += 2
+						continue
+					}
+				}
+				j++
+			}
+			if !found {
+				i = n // subnegotiation split across reads; drop the remainder
+			}
+		default:
+			// IAC WILL/DO/WONT/DONT <option>: skip the command and option bytes.
+			i += 2
+		}
+	}
+	copy(p, out)
+	return len(out), err
+}
+
+func (t *RFC2217Transport) Close() error { return t.conn.Close() }
@@ -0,0 +1,164 @@
+package serial
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEscapeIAC(t *testing.T) {
+	in := []byte{0x01, telnetIAC, 0x02, telnetIAC, telnetIAC}
+	want := []byte{0x01, telnetIAC, telnetIAC, 0x02, telnetIAC, telnetIAC, telnetIAC, telnetIAC}
+	if got := escapeIAC(in); !bytes.Equal(got, want) {
+		t.Fatalf("escapeIAC(%x) = %x, want %x", in, got, want)
+	}
+}
+
+func TestStopBitsToRFC2217(t *testing.T) {
+	cases := []struct {
+		in   StopBits
+		want byte
+	}{
+		{Stop1, 1},
+		{Stop1Half, 3},
+		{Stop2, 2},
+	}
+	for _, c := range cases {
+		if got := stopBitsToRFC2217(c.in); got != c.want {
+			t.Errorf("stopBitsToRFC2217(%v) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
+
+// TestRFC2217TransportWriteEscapesIAC checks that Write escapes a literal
+// IAC byte in the payload stream, as RFC 2217 requires.
+func TestRFC2217TransportWriteEscapesIAC(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	xport := &RFC2217Transport{conn: client}
+	done := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 16)
+		n, _ := server.Read(buf)
+		done <- buf[:n]
+	}()
+
+	payload := []byte{0x10, telnetIAC, 0x20}
+	n, err := xport.Write(payload)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if n != len(payload) {
+		t.Fatalf("Write returned n=%d, want %d", n, len(payload))
+	}
+
+	select {
+	case got := <-done:
+		want := []byte{0x10, telnetIAC, telnetIAC, 0x20}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("bytes on the wire = %x, want %x", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for server read")
+	}
+}
+
+// TestRFC2217TransportReadStripsCommands checks that Read unescapes a
+// doubled IAC and drops an in-band WILL/DO command, handing the caller
+// only the serial payload bytes.
+func TestRFC2217TransportReadStripsCommands(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	xport := &RFC2217Transport{conn: client}
+	go func() {
+		// 0xAA, escaped IAC, WILL COM-PORT-OPTION (dropped), 0xBB.
+		server.Write([]byte{0xAA, telnetIAC, telnetIAC, telnetIAC, telnetWILL, rfc2217ComPortOption, 0xBB})
+	}()
+
+	buf := make([]byte, 16)
+	n, err := xport.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []byte{0xAA, telnetIAC, 0xBB}
+	if !bytes.Equal(buf[:n], want) {
+		t.Fatalf("Read = %x, want %x", buf[:n], want)
+	}
+}
+
+// TestRFC2217TransportReadSubnegotiationWithEmbeddedSE checks that Read
+// only treats the literal IAC SE pair as ending a subnegotiation, not a
+// bare byte that happens to equal SE among the subnegotiation parameters,
+// and that real payload data following the subnegotiation survives intact.
+func TestRFC2217TransportReadSubnegotiationWithEmbeddedSE(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	xport := &RFC2217Transport{conn: client}
+	go func() {
+		// IAC SB COM-PORT-OPTION <param containing a bare 0xF0 byte> IAC SE,
+		// followed by real payload data that must not be dropped.
+		server.Write([]byte{
+			0xAA,
+			telnetIAC, telnetSB, rfc2217ComPortOption, 0x01, telnetSE, 0x02,
+			telnetIAC, telnetSE,
+			0xBB,
+		})
+	}()
+
+	buf := make([]byte, 16)
+	n, err := xport.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []byte{0xAA, 0xBB}
+	if !bytes.Equal(buf[:n], want) {
+		t.Fatalf("Read = %x, want %x", buf[:n], want)
+	}
+}
+
+// TestTCPTransportRoundtrip drives TCPTransport against a local TCP
+// listener, confirming Open/Write/Read pass bytes through unmodified.
+func TestTCPTransportRoundtrip(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 5)
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+		conn.Write(buf)
+	}()
+
+	xport := NewTCPTransport(ln.Addr().String())
+	if err := xport.Open(Config{}); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer xport.Close()
+
+	if _, err := xport.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := xport.Read(buf); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(buf, []byte("hello")) {
+		t.Fatalf("Read = %q, want %q", buf, "hello")
+	}
+}
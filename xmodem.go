@@ -0,0 +1,401 @@
+package serial
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// XMODEM/YMODEM control bytes.
+const (
+	xmodemSOH byte = 0x01 // 128-byte data block follows
+	xmodemSTX byte = 0x02 // 1024-byte data block follows
+	xmodemEOT byte = 0x04 // end of transmission
+	xmodemACK byte = 0x06 // block accepted
+	xmodemNAK byte = 0x15 // block rejected, retry
+	xmodemCAN byte = 0x18 // transfer canceled
+	xmodemCRC byte = 0x43 // 'C', receiver requests CRC-16 mode
+)
+
+const (
+	xmodemBlockSize  = 128  // SOH block payload size
+	ymodemBlockSize  = 1024 // STX block payload size
+	xmodemMaxRetries = 10
+)
+
+// SendXMODEM sends filepath using XMODEM-1K/CRC framing: the receiver
+// requests CRC-16 mode with a leading 'C', each block is
+// [STX|blockNum|~blockNum|1024 bytes data|CRC-hi|CRC-lo], with a remainder
+// under 1024 bytes sent as SOH blocks of 128 bytes, and the transfer
+// ends with EOT. progress, if non-nil, is called after every
+// acknowledged block. ctx cancels the transfer between blocks.
+func (sp *SerialPort) SendXMODEM(ctx context.Context, filepath string, progress func(sent, total int64)) error {
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	return sp.sendXMODEMBlocks(ctx, data, progress)
+}
+
+// SendYMODEM sends filepath using YMODEM batch framing: block 0 carries
+// "name\0size mtime\0" as a null-terminated ASCII header, the file data
+// follows in 1024-byte STX blocks (a remainder under 1024 bytes goes out
+// as 128-byte SOH blocks, the last one padded with 0x1A), and the batch
+// ends with an empty block 0.
+func (sp *SerialPort) SendYMODEM(ctx context.Context, path string, progress func(sent, total int64)) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if err := sp.awaitCRCRequest(ctx); err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf("%s\x00%d %o\x00", filepath.Base(path), info.Size(), info.ModTime().Unix())
+	if err := sp.sendBlock(ctx, 0, []byte(header), ymodemBlockSize); err != nil {
+		return err
+	}
+
+	if err := sp.awaitCRCRequest(ctx); err != nil {
+		return err
+	}
+	if err := sp.sendXMODEMBlocks(ctx, data, progress); err != nil {
+		return err
+	}
+
+	// Empty block 0 terminates the batch.
+	if err := sp.awaitCRCRequest(ctx); err != nil {
+		return err
+	}
+	return sp.sendBlock(ctx, 0, nil, ymodemBlockSize)
+}
+
+// sendXMODEMBlocks streams data in 1024-byte blocks (falling back to the
+// classic 128-byte size for the final partial block), requiring the
+// receiver to already be waiting in CRC mode, and finishes with EOT.
+func (sp *SerialPort) sendXMODEMBlocks(ctx context.Context, data []byte, progress func(sent, total int64)) error {
+	if err := sp.awaitCRCRequest(ctx); err != nil {
+		return err
+	}
+
+	total := int64(len(data))
+	var sent int64
+	blockNum := byte(1)
+	for len(data) > 0 {
+		n := ymodemBlockSize
+		if n > len(data) {
+			n = xmodemBlockSize
+			if n > len(data) {
+				n = len(data)
+			}
+		}
+		block := data[:n]
+		data = data[n:]
+
+		if err := sp.sendBlock(ctx, blockNum, block, ymodemBlockSize); err != nil {
+			return err
+		}
+		sent += int64(n)
+		blockNum++
+		if progress != nil {
+			progress(sent, total)
+		}
+	}
+
+	return sp.finishWithEOT(ctx)
+}
+
+// sendBlock pads payload to blockSize (1024 uses STX, 128 or less uses
+// SOH) with 0x1A, attaches the block number and its one's complement and a
+// CRC-16/XMODEM trailer, and retries on NAK up to xmodemMaxRetries times.
+func (sp *SerialPort) sendBlock(ctx context.Context, blockNum byte, payload []byte, blockSize int) error {
+	size := xmodemBlockSize
+	marker := xmodemSOH
+	if blockSize == ymodemBlockSize && len(payload) > xmodemBlockSize {
+		size = ymodemBlockSize
+		marker = xmodemSTX
+	}
+
+	padded := make([]byte, size)
+	copy(padded, payload)
+	for i := len(payload); i < size; i++ {
+		padded[i] = 0x1A
+	}
+
+	frame := make([]byte, 0, size+5)
+	frame = append(frame, marker, blockNum, ^blockNum)
+	frame = append(frame, padded...)
+	crc := crc16XMODEM(padded)
+	frame = append(frame, byte(crc>>8), byte(crc))
+
+	for attempt := 0; attempt < xmodemMaxRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if _, err := sp.port.Write(frame); err != nil {
+			return err
+		}
+		reply, err := sp.readByteTimeout(5 * time.Second)
+		if err != nil {
+			continue
+		}
+		switch reply {
+		case xmodemACK:
+			return nil
+		case xmodemCAN:
+			return fmt.Errorf("serial: transfer canceled by receiver")
+		}
+		// NAK or garbage: retry.
+	}
+	return fmt.Errorf("serial: block %d not acknowledged after %d retries", blockNum, xmodemMaxRetries)
+}
+
+// finishWithEOT sends EOT and waits for the final ACK, retrying as some
+// receivers NAK the first EOT.
+func (sp *SerialPort) finishWithEOT(ctx context.Context) error {
+	for attempt := 0; attempt < xmodemMaxRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if _, err := sp.port.Write([]byte{xmodemEOT}); err != nil {
+			return err
+		}
+		reply, err := sp.readByteTimeout(5 * time.Second)
+		if err == nil && reply == xmodemACK {
+			return nil
+		}
+	}
+	return fmt.Errorf("serial: EOT not acknowledged after %d retries", xmodemMaxRetries)
+}
+
+// awaitCRCRequest blocks until the receiver signals it is ready with a 'C'
+// byte, which it repeats periodically while waiting.
+func (sp *SerialPort) awaitCRCRequest(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		b, err := sp.readByteTimeout(time.Second)
+		if err == nil && b == xmodemCRC {
+			return nil
+		}
+	}
+}
+
+// ReceiveYMODEM receives a YMODEM batch into destDir, requesting CRC mode
+// and writing each transferred file using the name and size from its
+// block-0 header. It stops when the sender transmits an empty block 0.
+func (sp *SerialPort) ReceiveYMODEM(ctx context.Context, destDir string) error {
+	for {
+		header, eof, err := sp.receiveFileBlocks(ctx, "", 0)
+		if err != nil {
+			return err
+		}
+		if eof {
+			return nil
+		}
+		name, size := parseYMODEMHeader(header.name)
+		if name == "" {
+			return nil
+		}
+
+		// Data blocks keep arriving until the sender's EOT, so keep
+		// calling receiveFileBlocks and concatenating until it reports
+		// end-of-file; a single call only ever yields one block.
+		var data []byte
+		next := byte(1)
+		for {
+			block, fileDone, err := sp.receiveFileBlocks(ctx, name, next)
+			if err != nil {
+				return err
+			}
+			if fileDone {
+				break
+			}
+			data = append(data, block.data...)
+			next++
+		}
+		if int64(len(data)) > size && size > 0 {
+			data = data[:size]
+		}
+		if err := ioutil.WriteFile(filepath.Join(destDir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+}
+
+type ymodemBlock struct {
+	name string
+	data []byte
+}
+
+// receiveFileBlocks requests CRC mode and reads a single SOH/STX block, or
+// the terminating EOT, ACKing it. Callers loop it to read a whole file's
+// blocks until it reports EOT. expect is the block number this call should
+// accept (0 for the YMODEM header block, otherwise the next sequential data
+// block); a repeat of the previous block number is treated as our own ACK
+// having been lost and is re-acknowledged without being stored again. label
+// is only used for error messages.
+func (sp *SerialPort) receiveFileBlocks(ctx context.Context, label string, expect byte) (ymodemBlock, bool, error) {
+retry:
+	for attempt := 0; attempt < xmodemMaxRetries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ymodemBlock{}, false, ctx.Err()
+		default:
+		}
+		if _, err := sp.port.Write([]byte{xmodemCRC}); err != nil {
+			return ymodemBlock{}, false, err
+		}
+
+		marker, err := sp.readByteTimeout(3 * time.Second)
+		if err != nil {
+			continue
+		}
+		if marker == xmodemEOT {
+			sp.port.Write([]byte{xmodemACK})
+			return ymodemBlock{}, true, nil
+		}
+		if marker != xmodemSOH && marker != xmodemSTX {
+			continue
+		}
+
+		size := xmodemBlockSize
+		if marker == xmodemSTX {
+			size = ymodemBlockSize
+		}
+
+		blockNum, err1 := sp.readByteTimeout(time.Second)
+		inv, err2 := sp.readByteTimeout(time.Second)
+		if err1 != nil || err2 != nil || blockNum != ^inv {
+			sp.port.Write([]byte{xmodemNAK})
+			continue
+		}
+
+		payload := make([]byte, size)
+		for i := range payload {
+			b, err := sp.readByteTimeout(time.Second)
+			if err != nil {
+				// Abort this whole block on a mid-block timeout rather
+				// than limping on with a zeroed byte: NAK and retry the
+				// block from the top instead of desyncing the handshake.
+				sp.port.Write([]byte{xmodemNAK})
+				continue retry
+			}
+			payload[i] = b
+		}
+		hi, err1 := sp.readByteTimeout(time.Second)
+		lo, err2 := sp.readByteTimeout(time.Second)
+		if err1 != nil || err2 != nil || crc16XMODEM(payload) != uint16(hi)<<8|uint16(lo) {
+			sp.port.Write([]byte{xmodemNAK})
+			continue
+		}
+
+		if blockNum != expect {
+			if blockNum == expect-1 {
+				// The sender resent a block we already accepted, most
+				// likely because our ACK for it was lost or corrupted
+				// in transit; re-ACK it without storing another copy
+				// and keep waiting for the real next block.
+				sp.port.Write([]byte{xmodemACK})
+				continue
+			}
+			sp.port.Write([]byte{xmodemNAK})
+			continue
+		}
+
+		if blockNum == 0 {
+			name := string(trimPadding(payload))
+			sp.port.Write([]byte{xmodemACK})
+			if name == "" {
+				return ymodemBlock{}, true, nil
+			}
+			return ymodemBlock{name: name}, false, nil
+		}
+
+		sp.port.Write([]byte{xmodemACK})
+		return ymodemBlock{data: trimPadding(payload)}, false, nil
+	}
+	return ymodemBlock{}, false, fmt.Errorf("serial: failed to receive %q after %d retries", label, xmodemMaxRetries)
+}
+
+func trimPadding(b []byte) []byte {
+	for len(b) > 0 && b[len(b)-1] == 0x1A {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// parseYMODEMHeader splits a YMODEM block-0 header of form
+// "name\0size mtime\0..." into the file name and size.
+func parseYMODEMHeader(header string) (name string, size int64) {
+	parts := splitNUL(header)
+	if len(parts) == 0 || parts[0] == "" {
+		return "", 0
+	}
+	name = parts[0]
+	if len(parts) > 1 {
+		fmt.Sscanf(parts[1], "%d", &size)
+	}
+	return name, size
+}
+
+func splitNUL(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0 {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// readByteTimeout reads a single byte from the port, failing if none
+// arrives within timeout.
+func (sp *SerialPort) readByteTimeout(timeout time.Duration) (byte, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		b, err := sp.Read()
+		if err == nil {
+			return b, nil
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return 0, fmt.Errorf("serial: timeout waiting for byte")
+}
+
+// crc16XMODEM computes CRC-16/XMODEM (poly 0x1021, init 0x0000, no
+// reflection), the variant used by XMODEM-CRC and YMODEM block trailers.
+func crc16XMODEM(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}